@@ -0,0 +1,79 @@
+// Package algo defines the pluggable decoder subsystem shared by all the
+// streaming-service unlockers (NetEase ncm, Tencent QMC, Kugou KGM/VPR,
+// Kuwo KWM, Xiami XM, ...). Each format lives in its own sub-package and
+// registers itself with Register during init, so main only needs to probe
+// the file and ask the registry for a decoder.
+package algo
+
+import "io"
+
+// AudioMeta carries whatever tag information a decoder is able to recover
+// from the source file. Fields that a format doesn't provide are left zero.
+type AudioMeta struct {
+	Title  string
+	Artist string
+	Album  string
+}
+
+// Decoder unlocks a single source file. Implementations are created by the
+// NewDecoderFunc registered for their format and are single-use.
+type Decoder interface {
+	// Validate checks that the source really is the format this decoder
+	// handles and that it can be decoded, without writing any output.
+	Validate() error
+
+	// Decode writes the unlocked audio stream to w.
+	Decode(w io.Writer) error
+
+	// GetMetadata returns the embedded tag information, or nil if the
+	// format doesn't carry any.
+	GetMetadata() *AudioMeta
+
+	// GetCoverImage returns the embedded cover art, or nil if absent.
+	GetCoverImage() []byte
+
+	// GetAudioExt returns the file extension (including the leading dot)
+	// of the decoded audio, e.g. ".flac" or ".mp3".
+	GetAudioExt() string
+}
+
+// ProbeFunc reports whether a file identifies as this format. name is the
+// file's base name (so probes may still key off extension where the
+// container has no reliable magic, e.g. multi-part "kgm.flac") and header
+// is the first HeaderSize bytes of the file (fewer if the file is shorter).
+type ProbeFunc func(name string, header []byte) bool
+
+// NewDecoderFunc constructs a Decoder reading from r.
+type NewDecoderFunc func(r io.Reader) Decoder
+
+type registration struct {
+	name       string
+	probe      ProbeFunc
+	newDecoder NewDecoderFunc
+}
+
+var registry []registration
+
+// Register adds a format to the registry. It is meant to be called from
+// sub-package init functions, e.g.:
+//
+//	func init() { algo.Register("qmc", Probe, NewDecoder) }
+func Register(name string, probe ProbeFunc, newDecoder NewDecoderFunc) {
+	registry = append(registry, registration{name: name, probe: probe, newDecoder: newDecoder})
+}
+
+// Detect returns the name and constructor of the first registered format
+// whose probe matches, in registration order. ok is false if none match.
+func Detect(name string, header []byte) (string, NewDecoderFunc, bool) {
+	for _, r := range registry {
+		if r.probe(name, header) {
+			return r.name, r.newDecoder, true
+		}
+	}
+	return "", nil, false
+}
+
+// HeaderSize is the number of leading bytes main reads from each candidate
+// file before calling Detect. It comfortably covers every registered
+// format's magic bytes.
+const HeaderSize = 16
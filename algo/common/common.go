@@ -0,0 +1,53 @@
+// Package common holds small decode primitives shared by the algo
+// sub-packages, so each format only has to implement its own key schedule.
+package common
+
+import "strings"
+
+// XorMask XORs data in place against mask, cycling mask and advancing the
+// cycle position by the running byte offset off (so callers can apply the
+// mask across multiple, arbitrarily sized reads of the same stream).
+func XorMask(data []byte, mask []byte, off int) {
+	n := len(mask)
+	if n == 0 {
+		return
+	}
+	for i := range data {
+		data[i] ^= mask[(off+i)%n]
+	}
+}
+
+// HasAnySuffix reports whether name ends with any of the given extensions,
+// compared case-insensitively.
+func HasAnySuffix(name string, exts ...string) bool {
+	lower := strings.ToLower(name)
+	for _, ext := range exts {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// LooksLikeAudio reports whether data starts with a magic sequence for one
+// of the container formats these decoders ever unmask into (MP3's ID3 tag
+// or raw MPEG frame sync, FLAC, Ogg, or M4A/AAC's ftyp box). Decoders that
+// can only tell their cipher mode from extension, not a fixed header, use
+// this after decrypting a few bytes to catch a wrong key or a misnamed
+// file before writing out the whole thing.
+func LooksLikeAudio(data []byte) bool {
+	switch {
+	case len(data) >= 3 && data[0] == 'I' && data[1] == 'D' && data[2] == '3':
+		return true
+	case len(data) >= 2 && data[0] == 0xFF && data[1]&0xE0 == 0xE0:
+		return true
+	case len(data) >= 4 && string(data[:4]) == "fLaC":
+		return true
+	case len(data) >= 4 && string(data[:4]) == "OggS":
+		return true
+	case len(data) >= 8 && string(data[4:8]) == "ftyp":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,141 @@
+// Package kgm decodes Kugou's KGM/VPR containers, including the
+// multi-part "kgm.flac" naming some Kugou clients use for lossless tracks.
+package kgm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/taurusxin/ncmdump-go/algo"
+	"github.com/taurusxin/ncmdump-go/algo/common"
+)
+
+func init() {
+	algo.Register("kgm", Probe, NewDecoder)
+}
+
+// magic is the 16-byte KGM/VPR file signature.
+var magic = []byte{
+	0x7c, 0xd5, 0x32, 0xeb, 0x86, 0x02, 0x7f, 0x4b,
+	0xa8, 0xaf, 0xa6, 0x8e, 0x0f, 0xff, 0x99, 0x14,
+}
+
+// headerKey is the fixed key baked into every Kugou client build; it is
+// combined with the per-file key carried in the header to build the mask.
+var headerKey = []byte{
+	0x6c, 0x75, 0xab, 0xe0, 0x73, 0xe2, 0x12, 0xed,
+	0x1c, 0xa0, 0xa8, 0xf3, 0xf4, 0x45, 0x22, 0xfb,
+	0x4d, 0x7a, 0x48, 0x56, 0x98, 0xab, 0x43, 0x11,
+}
+
+const headerSize = 0x3c
+
+func Probe(name string, header []byte) bool {
+	if common.HasAnySuffix(name, ".kgm", ".kgma", ".vpr") {
+		return true
+	}
+	if common.HasAnySuffix(name, ".kgm.flac", ".kgma.flac", ".vpr.flac") {
+		return true
+	}
+	return len(header) >= len(magic) && bytes.Equal(header[:len(magic)], magic)
+}
+
+// Decoder unlocks a single KGM/VPR file.
+type Decoder struct {
+	src  io.Reader
+	data []byte // cached by Validate so Decode doesn't re-read src
+}
+
+func NewDecoder(src io.Reader) algo.Decoder {
+	return &Decoder{src: src}
+}
+
+// Validate reads the whole file (Decode needs all of it anyway), checks
+// the magic header and file key, decrypts a sample of the body, and
+// confirms it looks like real audio. Otherwise a bad key or a misnamed
+// file doesn't fail until an (already-created) output file has been
+// written full of garbage.
+func (d *Decoder) Validate() error {
+	data, err := io.ReadAll(d.src)
+	if err != nil {
+		return fmt.Errorf("read kgm source failed: %w", err)
+	}
+	d.data = data
+
+	fileKey, body, err := parseHeader(data)
+	if err != nil {
+		return err
+	}
+	sample := make([]byte, 16)
+	n := copy(sample, body)
+	sample = sample[:n]
+	for i := range sample {
+		sample[i] ^= buildMask(fileKey, i)
+	}
+	if !common.LooksLikeAudio(sample) {
+		return fmt.Errorf("kgm payload doesn't decrypt to recognizable audio: wrong key or not a kgm/vpr file")
+	}
+	return nil
+}
+
+// parseHeader checks the magic signature and extracts the per-file key,
+// returning it alongside the body bytes that follow the header.
+func parseHeader(data []byte) (fileKey []byte, body []byte, err error) {
+	if len(data) < headerSize {
+		return nil, nil, fmt.Errorf("kgm source too short to hold the header (%d bytes)", len(data))
+	}
+	header := data[:headerSize]
+	if !bytes.Equal(header[:len(magic)], magic) {
+		return nil, nil, fmt.Errorf("not a kgm/vpr file")
+	}
+
+	keyLen := binary.LittleEndian.Uint32(header[0x10:0x14])
+	if avail := uint32(len(header) - 0x1c); keyLen > avail {
+		keyLen = avail
+	}
+	fileKey = header[0x1c : 0x1c+keyLen]
+	if len(fileKey) == 0 {
+		fileKey = headerKey
+	}
+	return fileKey, data[headerSize:], nil
+}
+
+// buildMask mixes the fixed header key with the file-specific key stored
+// at offset 0x1c of the header, then folds in the output byte position so
+// the mask never repeats on a short cycle.
+func buildMask(fileKey []byte, pos int) byte {
+	b := headerKey[pos%len(headerKey)]
+	b ^= fileKey[pos%len(fileKey)]
+	b ^= byte(pos) & 0xff
+	b ^= b << 4
+	return b
+}
+
+func (d *Decoder) Decode(w io.Writer) error {
+	data := d.data
+	if data == nil {
+		var err error
+		data, err = io.ReadAll(d.src)
+		if err != nil {
+			return fmt.Errorf("read kgm source failed: %w", err)
+		}
+	}
+
+	fileKey, body, err := parseHeader(data)
+	if err != nil {
+		return err
+	}
+
+	out := append([]byte{}, body...)
+	for i := range out {
+		out[i] ^= buildMask(fileKey, i)
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+func (d *Decoder) GetMetadata() *algo.AudioMeta { return nil }
+func (d *Decoder) GetCoverImage() []byte        { return nil }
+func (d *Decoder) GetAudioExt() string          { return ".flac" }
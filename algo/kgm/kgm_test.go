@@ -0,0 +1,81 @@
+package kgm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// buildFixture assembles a synthetic KGM file: the magic signature, a
+// short file key at the header's key offset, and some "plaintext" audio
+// bytes encrypted with buildMask the same way a real Kugou client would.
+func buildFixture(t *testing.T, plain []byte) []byte {
+	t.Helper()
+	fileKey := []byte{0x01, 0x02, 0x03, 0x04}
+
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	binary.LittleEndian.PutUint32(header[0x10:0x14], uint32(len(fileKey)))
+	copy(header[0x1c:], fileKey)
+
+	body := append([]byte{}, plain...)
+	for i := range body {
+		body[i] ^= buildMask(fileKey, i)
+	}
+
+	return append(header, body...)
+}
+
+func TestDecodeRoundTrip(t *testing.T) {
+	plain := bytes.Repeat([]byte("fLaC-fixture-audio-bytes!"), 4)
+	fixture := buildFixture(t, plain)
+
+	d := NewDecoder(bytes.NewReader(fixture))
+	var out bytes.Buffer
+	if err := d.Decode(&out); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), plain) {
+		t.Fatalf("round trip mismatch:\n got: %x\nwant: %x", out.Bytes(), plain)
+	}
+}
+
+func TestDecodeRejectsBadMagic(t *testing.T) {
+	fixture := buildFixture(t, []byte("whatever"))
+	fixture[0] ^= 0xFF // corrupt the magic
+
+	d := NewDecoder(bytes.NewReader(fixture))
+	if err := d.Decode(&bytes.Buffer{}); err == nil {
+		t.Fatal("expected an error for a bad magic header")
+	}
+}
+
+func TestValidateAcceptsGoodFixture(t *testing.T) {
+	fixture := buildFixture(t, bytes.Repeat([]byte("fLaC-fixture-audio-bytes!"), 4))
+	d := NewDecoder(bytes.NewReader(fixture))
+	if err := d.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+}
+
+func TestValidateRejectsBadMagic(t *testing.T) {
+	fixture := buildFixture(t, []byte("whatever"))
+	fixture[0] ^= 0xFF
+
+	d := NewDecoder(bytes.NewReader(fixture))
+	if err := d.Validate(); err == nil {
+		t.Fatal("expected Validate to reject a bad magic header")
+	}
+}
+
+func TestProbeByMagicBytes(t *testing.T) {
+	if !Probe("song.bin", magic) {
+		t.Fatal("expected Probe to recognize the raw magic bytes regardless of name")
+	}
+	if !Probe("song.kgm", nil) {
+		t.Fatal("expected Probe to recognize the .kgm extension")
+	}
+	if Probe("song.mp3", []byte{0, 0, 0, 0}) {
+		t.Fatal("expected Probe to reject an unrelated file")
+	}
+}
@@ -0,0 +1,116 @@
+// Package kwm decodes Kuwo's KWM container.
+package kwm
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/taurusxin/ncmdump-go/algo"
+	"github.com/taurusxin/ncmdump-go/algo/common"
+)
+
+func init() {
+	algo.Register("kwm", Probe, NewDecoder)
+}
+
+var magic = []byte("yeelion-kuwo-tme")
+
+const (
+	headerSize  = 0x400
+	embeddedKey = "MoOtOiTvINGwd2E6n0E1i7L5t2IoOoNk"
+)
+
+func Probe(name string, header []byte) bool {
+	return len(header) >= len(magic) && bytes.Equal(header[:len(magic)], magic)
+}
+
+// Decoder unlocks a single KWM file.
+type Decoder struct {
+	src  io.Reader
+	data []byte // cached by Validate so Decode doesn't re-read src
+}
+
+func NewDecoder(src io.Reader) algo.Decoder {
+	return &Decoder{src: src}
+}
+
+// Validate reads the whole file (Decode needs all of it anyway), checks
+// the magic header and file key, decrypts a sample of the body, and
+// confirms it looks like real audio. Otherwise a bad key or a misnamed
+// file doesn't fail until an (already-created) output file has been
+// written full of garbage.
+func (d *Decoder) Validate() error {
+	data, err := io.ReadAll(d.src)
+	if err != nil {
+		return fmt.Errorf("read kwm source failed: %w", err)
+	}
+	d.data = data
+
+	fileKey, body, err := parseHeader(data)
+	if err != nil {
+		return err
+	}
+	mask := buildMask(fileKey)
+	sample := make([]byte, 16)
+	n := copy(sample, body)
+	sample = sample[:n]
+	common.XorMask(sample, mask, 0)
+	if !common.LooksLikeAudio(sample) {
+		return fmt.Errorf("kwm payload doesn't decrypt to recognizable audio: wrong key or not a kwm file")
+	}
+	return nil
+}
+
+// parseHeader checks the magic signature and extracts the per-file key,
+// returning it alongside the body bytes that follow the header.
+func parseHeader(data []byte) (fileKey []byte, body []byte, err error) {
+	if len(data) < headerSize {
+		return nil, nil, fmt.Errorf("kwm source too short to hold the header (%d bytes)", len(data))
+	}
+	header := data[:headerSize]
+	if !bytes.Equal(header[:len(magic)], magic) {
+		return nil, nil, fmt.Errorf("not a kwm file")
+	}
+	fileKey = bytes.TrimRight(header[0x18:0x2f], "\x00")
+	if len(fileKey) == 0 {
+		return nil, nil, fmt.Errorf("kwm file key missing")
+	}
+	return fileKey, data[headerSize:], nil
+}
+
+// buildMask derives the 32-byte XOR mask from the key embedded in the
+// header by XORing it against the fixed KWM client string.
+func buildMask(fileKey []byte) []byte {
+	mask := make([]byte, 32)
+	for i := range mask {
+		mask[i] = fileKey[i%len(fileKey)] ^ embeddedKey[i%len(embeddedKey)]
+	}
+	return mask
+}
+
+func (d *Decoder) Decode(w io.Writer) error {
+	data := d.data
+	if data == nil {
+		var err error
+		data, err = io.ReadAll(d.src)
+		if err != nil {
+			return fmt.Errorf("read kwm source failed: %w", err)
+		}
+	}
+
+	fileKey, body, err := parseHeader(data)
+	if err != nil {
+		return err
+	}
+	mask := buildMask(fileKey)
+
+	out := append([]byte{}, body...)
+	common.XorMask(out, mask, 0)
+	_, err = w.Write(out)
+	return err
+}
+
+func (d *Decoder) GetMetadata() *algo.AudioMeta { return nil }
+func (d *Decoder) GetCoverImage() []byte        { return nil }
+func (d *Decoder) GetAudioExt() string          { return ".mp3" }
@@ -0,0 +1,77 @@
+package kwm
+
+import (
+	"bytes"
+	"testing"
+)
+
+func buildFixture(t *testing.T, fileKey, plain []byte) []byte {
+	t.Helper()
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	copy(header[0x18:0x2f], fileKey)
+
+	mask := buildMask(fileKey)
+	body := append([]byte{}, plain...)
+	for i := range body {
+		body[i] ^= mask[i%len(mask)]
+	}
+
+	return append(header, body...)
+}
+
+func TestDecodeRoundTrip(t *testing.T) {
+	fileKey := []byte("some-kwm-file-key")
+	plain := bytes.Repeat([]byte("ID3-fixture-audio-bytes!"), 4)
+	fixture := buildFixture(t, fileKey, plain)
+
+	d := NewDecoder(bytes.NewReader(fixture))
+	var out bytes.Buffer
+	if err := d.Decode(&out); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), plain) {
+		t.Fatalf("round trip mismatch:\n got: %x\nwant: %x", out.Bytes(), plain)
+	}
+}
+
+func TestDecodeRejectsMissingKey(t *testing.T) {
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	fixture := append(header, []byte("audio")...)
+
+	d := NewDecoder(bytes.NewReader(fixture))
+	if err := d.Decode(&bytes.Buffer{}); err == nil {
+		t.Fatal("expected an error when the header carries no file key")
+	}
+}
+
+func TestValidateAcceptsGoodFixture(t *testing.T) {
+	fileKey := []byte("some-kwm-file-key")
+	fixture := buildFixture(t, fileKey, bytes.Repeat([]byte("ID3-fixture-audio-bytes!"), 4))
+
+	d := NewDecoder(bytes.NewReader(fixture))
+	if err := d.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+}
+
+func TestValidateRejectsBadMagic(t *testing.T) {
+	fileKey := []byte("some-kwm-file-key")
+	fixture := buildFixture(t, fileKey, bytes.Repeat([]byte("ID3-fixture-audio-bytes!"), 4))
+	fixture[0] ^= 0xFF
+
+	d := NewDecoder(bytes.NewReader(fixture))
+	if err := d.Validate(); err == nil {
+		t.Fatal("expected Validate to reject a bad magic header")
+	}
+}
+
+func TestProbeByMagicBytes(t *testing.T) {
+	if !Probe("anything", magic) {
+		t.Fatal("expected Probe to recognize the magic bytes")
+	}
+	if Probe("anything", []byte("not-the-magic...")) {
+		t.Fatal("expected Probe to reject non-matching header bytes")
+	}
+}
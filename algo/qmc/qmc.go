@@ -0,0 +1,194 @@
+// Package qmc decodes Tencent QQ Music's QMC container family:
+// .qmc0/.qmc3 (static-mask cipher) and .mflac/.mgg (RC4-style mask seeded
+// from an embedded key).
+package qmc
+
+import (
+	"bytes"
+	"crypto/rc4"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/taurusxin/ncmdump-go/algo"
+	"github.com/taurusxin/ncmdump-go/algo/common"
+)
+
+func init() {
+	algo.Register("qmc", Probe, NewDecoder)
+}
+
+var staticExts = []string{".qmc0", ".qmcflac", ".qmc3", ".qmc2"}
+var rc4Exts = []string{".mflac", ".mflac0", ".mgg", ".mgg1"}
+
+// staticMask is the fixed 128-byte XOR table used by the legacy QMC
+// container. The cipher simply cycles this table against the stream.
+var staticMask = [128]byte{
+	0x77, 0x48, 0x32, 0x73, 0xde, 0xf2, 0xc0, 0xc8,
+	0x95, 0xec, 0x30, 0xb2, 0x51, 0xc3, 0xe1, 0xa0,
+	0x9e, 0xe6, 0x9d, 0xcf, 0xfa, 0x7f, 0x14, 0xd1,
+	0xce, 0xb8, 0xdc, 0xc3, 0x4a, 0x67, 0x93, 0xd6,
+	0x28, 0xc2, 0x91, 0x70, 0xca, 0x8d, 0xf4, 0x09,
+	0xa3, 0x67, 0x14, 0x7d, 0x27, 0xbf, 0x73, 0xc4,
+	0x27, 0x10, 0x95, 0x30, 0x21, 0xae, 0x47, 0xf1,
+	0xbc, 0x91, 0x6f, 0x2e, 0x5f, 0x5a, 0x42, 0xb1,
+	0xc6, 0xd9, 0x5b, 0xca, 0xe6, 0xbd, 0x5c, 0x4e,
+	0x2e, 0xa9, 0x9b, 0x6b, 0x5b, 0x9f, 0x2a, 0x0a,
+	0xe6, 0xd9, 0x4a, 0x13, 0xba, 0xa2, 0x2b, 0xe7,
+	0x8c, 0x4d, 0x56, 0xd1, 0x3e, 0xdd, 0x33, 0x6b,
+	0x77, 0x54, 0x8a, 0xb3, 0x6c, 0x91, 0x5a, 0xae,
+	0xce, 0x9a, 0x49, 0xb1, 0x95, 0xe1, 0x5a, 0x6e,
+	0xe6, 0x4b, 0x8c, 0x41, 0x93, 0x0a, 0x98, 0x4c,
+	0x6e, 0x44, 0x45, 0x2c, 0x66, 0xe5, 0x5a, 0x74,
+}
+
+// Probe reports whether name looks like a QMC container we can handle.
+func Probe(name string, header []byte) bool {
+	return common.HasAnySuffix(name, staticExts...) || common.HasAnySuffix(name, rc4Exts...)
+}
+
+// Decoder unlocks a single QMC file.
+type Decoder struct {
+	src    io.Reader
+	name   string
+	ext    string
+	rc4Key []byte // non-nil once an embedded key has been recovered
+	out    string
+	data   []byte // cached by Validate so Decode doesn't re-read src
+}
+
+// NewDecoder returns a Decoder reading src. Since QMC carries its embedded
+// key (if any) as a tail-appended, base64-encoded blob rather than a fixed
+// header, the concrete mode is only settled once the data is read: the RC4
+// path when a "QTag" key trailer is found, the static mask otherwise. The
+// output extension defaults to ".mp3" until the caller supplies the file
+// name via SetName.
+func NewDecoder(src io.Reader) algo.Decoder {
+	return &Decoder{src: src, out: ".mp3"}
+}
+
+// SetName lets callers that know the original file name (main does, since
+// detection also needs it) pick the output extension GetAudioExt reports.
+// It does not affect the static vs. RC4 choice, which depends only on the
+// key trailer. It is not part of the algo.Decoder interface because most
+// formats don't need it.
+func (d *Decoder) SetName(name string) {
+	d.name = name
+	switch {
+	case common.HasAnySuffix(name, ".qmcflac", ".mflac", ".mflac0"):
+		d.out = ".flac"
+	case common.HasAnySuffix(name, ".mgg", ".mgg1"):
+		d.out = ".ogg"
+	}
+}
+
+// Validate reads the whole file (QMC's embedded key, if any, is a
+// tail-appended blob so there's no way around that), decrypts a few bytes
+// with whichever mode applies, and checks the result looks like real
+// audio. Extension-only detection can't otherwise tell a wrong key or a
+// misnamed file from a good one until the corrupted output is already on
+// disk.
+func (d *Decoder) Validate() error {
+	data, err := io.ReadAll(d.src)
+	if err != nil {
+		return fmt.Errorf("read qmc source failed: %w", err)
+	}
+	if len(data) < 16 {
+		return fmt.Errorf("qmc source too short to be a valid container (%d bytes)", len(data))
+	}
+	d.data = data
+
+	audio, key := splitEmbeddedKey(data)
+	if len(audio) < 16 {
+		return fmt.Errorf("qmc source too short to be a valid container (%d bytes of audio)", len(audio))
+	}
+	sample := make([]byte, 16)
+	copy(sample, audio[:16])
+	if key != nil {
+		cipher, err := rc4.NewCipher(key)
+		if err != nil {
+			return fmt.Errorf("build qmc rc4 cipher failed: %w", err)
+		}
+		cipher.XORKeyStream(sample, sample)
+	} else {
+		common.XorMask(sample, staticMask[:], 0)
+	}
+
+	if !common.LooksLikeAudio(sample) {
+		return fmt.Errorf("qmc payload doesn't decrypt to recognizable audio: wrong key or not a qmc file")
+	}
+	return nil
+}
+
+// splitEmbeddedKey looks for the "QTag" ekey trailer QMC appends to
+// mflac/mgg files: audio data, then the key base64-encoded, then a
+// 4-byte little-endian length of that base64 blob, then the "QTag"
+// marker itself. It returns the audio data with the trailer stripped off
+// and the decoded RC4 seed key, or a nil key if the file has no such
+// trailer (in which case the static mask applies over the whole file,
+// same as unlock-music's fallback behaviour).
+func splitEmbeddedKey(data []byte) (audio []byte, key []byte) {
+	if len(data) < 8 || !bytes.Equal(data[len(data)-4:], []byte("QTag")) {
+		return data, nil
+	}
+	rest := data[:len(data)-4]
+
+	if len(rest) < 4 {
+		return data, nil
+	}
+	keySize := binary.LittleEndian.Uint32(rest[len(rest)-4:])
+	rest = rest[:len(rest)-4]
+
+	if uint64(keySize) > uint64(len(rest)) {
+		return data, nil
+	}
+	raw := rest[uint32(len(rest))-keySize:]
+
+	decodedKey, err := base64.StdEncoding.DecodeString(string(raw))
+	if err != nil || len(decodedKey) == 0 {
+		return data, nil
+	}
+	return rest[:uint32(len(rest))-keySize], decodedKey
+}
+
+// Decode unmasks the static-mask path exactly as unlock-music does. For
+// the embedded-key (mflac/mgg) path it currently runs the recovered key
+// through stdlib crypto/rc4's textbook KSA+PRGA. Real-world QMC2 captures
+// are reported (by unlock-music and other prior art) to use a
+// non-standard, segmented RC4 variant rather than plain RC4, which this
+// package has not been validated against: qmc_test.go's embedded-key
+// round trip only proves this code is internally consistent with itself
+// (it encrypts its own fixture with the same crypto/rc4 call it decodes
+// with), not that it reproduces a real mflac/mgg file. Treat this path as
+// unverified until it's been run against an actual captured sample.
+func (d *Decoder) Decode(w io.Writer) error {
+	data := d.data
+	if data == nil {
+		var err error
+		data, err = io.ReadAll(d.src)
+		if err != nil {
+			return fmt.Errorf("read qmc source failed: %w", err)
+		}
+	}
+
+	audio, key := splitEmbeddedKey(data)
+	if key != nil {
+		cipher, err := rc4.NewCipher(key)
+		if err != nil {
+			return fmt.Errorf("build qmc rc4 cipher failed: %w", err)
+		}
+		out := make([]byte, len(audio))
+		cipher.XORKeyStream(out, audio)
+		_, err = w.Write(out)
+		return err
+	}
+
+	common.XorMask(audio, staticMask[:], 0)
+	_, err := w.Write(audio)
+	return err
+}
+
+func (d *Decoder) GetMetadata() *algo.AudioMeta { return nil }
+func (d *Decoder) GetCoverImage() []byte        { return nil }
+func (d *Decoder) GetAudioExt() string          { return d.out }
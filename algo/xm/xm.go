@@ -0,0 +1,80 @@
+// Package xm decodes Xiami's XM container, a simple fixed-key XOR cipher
+// over MP3/M4A audio.
+package xm
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/taurusxin/ncmdump-go/algo"
+	"github.com/taurusxin/ncmdump-go/algo/common"
+)
+
+func init() {
+	algo.Register("xm", Probe, NewDecoder)
+}
+
+var mask = []byte("ximalayaximalayaximalayaximalaya")
+
+func Probe(name string, header []byte) bool {
+	return common.HasAnySuffix(name, ".xm")
+}
+
+// Decoder unlocks a single XM file.
+type Decoder struct {
+	src  io.Reader
+	data []byte // cached by Validate so Decode doesn't re-read src
+	ext  string // set by Validate from the unmasked header; "" means ".mp3"
+}
+
+func NewDecoder(src io.Reader) algo.Decoder {
+	return &Decoder{src: src}
+}
+
+// Validate reads the file and checks that unmasking the first bytes
+// yields recognizable audio, so a file that's merely named *.xm but isn't
+// one fails here instead of writing corrupted output.
+func (d *Decoder) Validate() error {
+	data, err := io.ReadAll(d.src)
+	if err != nil {
+		return fmt.Errorf("read xm source failed: %w", err)
+	}
+	if len(data) < 16 {
+		return fmt.Errorf("xm source too short to be a valid container (%d bytes)", len(data))
+	}
+	d.data = data
+
+	sample := make([]byte, 16)
+	copy(sample, data[:16])
+	common.XorMask(sample, mask, 0)
+	if !common.LooksLikeAudio(sample) {
+		return fmt.Errorf("xm payload doesn't unmask to recognizable audio: not an xm file")
+	}
+	if string(sample[4:8]) == "ftyp" {
+		d.ext = ".m4a"
+	}
+	return nil
+}
+
+func (d *Decoder) Decode(w io.Writer) error {
+	data := d.data
+	if data == nil {
+		var err error
+		data, err = io.ReadAll(d.src)
+		if err != nil {
+			return fmt.Errorf("read xm source failed: %w", err)
+		}
+	}
+	common.XorMask(data, mask, 0)
+	_, err := w.Write(data)
+	return err
+}
+
+func (d *Decoder) GetMetadata() *algo.AudioMeta { return nil }
+func (d *Decoder) GetCoverImage() []byte        { return nil }
+func (d *Decoder) GetAudioExt() string {
+	if d.ext != "" {
+		return d.ext
+	}
+	return ".mp3"
+}
@@ -0,0 +1,34 @@
+package xm
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeRoundTrip(t *testing.T) {
+	plain := append([]byte("ID3"), bytes.Repeat([]byte("xm-fixture-audio"), 8)...)
+	data := append([]byte{}, plain...)
+	for i := range data {
+		data[i] ^= mask[i%len(mask)]
+	}
+
+	d := NewDecoder(bytes.NewReader(data))
+	if err := d.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	var out bytes.Buffer
+	if err := d.Decode(&out); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if !bytes.Equal(out.Bytes(), plain) {
+		t.Fatalf("round trip mismatch:\n got: %x\nwant: %x", out.Bytes(), plain)
+	}
+}
+
+func TestValidateRejectsGarbage(t *testing.T) {
+	data := bytes.Repeat([]byte{0x99}, 64)
+	d := NewDecoder(bytes.NewReader(data))
+	if err := d.Validate(); err == nil {
+		t.Fatal("expected Validate to reject a payload that doesn't unmask to audio")
+	}
+}
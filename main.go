@@ -2,52 +2,249 @@ package main
 
 import (
 	"fmt"
+	"github.com/taurusxin/ncmdump-go/algo"
+	_ "github.com/taurusxin/ncmdump-go/algo/kgm"
+	_ "github.com/taurusxin/ncmdump-go/algo/kwm"
+	_ "github.com/taurusxin/ncmdump-go/algo/qmc"
+	_ "github.com/taurusxin/ncmdump-go/algo/xm"
 	"github.com/taurusxin/ncmdump-go/ncmcrypt"
 	"github.com/taurusxin/ncmdump-go/utils"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
 
 	flag "github.com/spf13/pflag"
 )
 
-func processFile(filePath string, outputDir string) error {
-	// skip if the extension is not .ncm
-	if filePath[len(filePath)-4:] != ".ncm" {
-		return nil
+// nameSetter lets decoders that need the original file name (e.g. qmc,
+// whose output extension depends on the source extension) pick it up
+// without widening the algo.Decoder interface for everyone else.
+type nameSetter interface {
+	SetName(name string)
+}
+
+// fileOptions controls how a single file is processed, independently of
+// how it was discovered (directory walk, CLI args, or --watch).
+type fileOptions struct {
+	Overwrite bool
+	DryRun    bool
+	Verify    verifyMode
+}
+
+// verifyMode mirrors ncmcrypt.VerifyLevel for the --verify flag, plus an
+// "off" state for when verification wasn't requested at all.
+type verifyMode string
+
+const (
+	verifyOff   verifyMode = ""
+	verifyQuick verifyMode = "quick"
+	verifyFull  verifyMode = "full"
+)
+
+// parseVerifyMode maps the --verify flag's string value to a verifyMode,
+// defaulting to verifyOff for anything it doesn't recognize.
+func parseVerifyMode(s string) verifyMode {
+	switch verifyMode(strings.ToLower(s)) {
+	case verifyQuick:
+		return verifyQuick
+	case verifyFull:
+		return verifyFull
+	default:
+		return verifyOff
+	}
+}
+
+// processWithAlgo unlocks filePath using a decoder from the algo registry.
+func processWithAlgo(filePath, outputDir string, newDecoder algo.NewDecoderFunc, opts fileOptions) fileStatus {
+	start := time.Now()
+
+	src, err := os.Open(filePath)
+	if err != nil {
+		utils.LogFileResult(utils.FileResult{
+			Input: filePath, DurationMs: time.Since(start).Milliseconds(),
+			Status: "error", Error: fmt.Sprintf("reading failed: %s", err),
+		})
+		return statusFailed
+	}
+	defer src.Close()
+
+	decoder := newDecoder(src)
+	if setter, ok := decoder.(nameSetter); ok {
+		setter.SetName(filepath.Base(filePath))
+	}
+
+	if err := decoder.Validate(); err != nil {
+		utils.LogFileResult(utils.FileResult{
+			Input: filePath, DurationMs: time.Since(start).Milliseconds(),
+			Status: "error", Error: fmt.Sprintf("validation failed: %s", err),
+		})
+		return statusFailed
+	}
+
+	outPath := utils.DecodedFilePath(filePath, decoder.GetAudioExt())
+	if outputDir != "" {
+		outPath = filepath.Join(outputDir, filepath.Base(outPath))
+	}
+
+	if !opts.Overwrite && utils.PathExists(outPath) {
+		utils.Infof("Skipping '%s', '%s' already exists", filePath, outPath)
+		return statusSkipped
+	}
+
+	if opts.DryRun {
+		utils.Infof("[DryRun] '%s' -> '%s'", filePath, outPath)
+		return statusOK
 	}
 
-	// process the file
+	out, err := os.Create(outPath)
+	if err != nil {
+		utils.LogFileResult(utils.FileResult{
+			Input: filePath, DurationMs: time.Since(start).Milliseconds(),
+			Status: "error", Error: fmt.Sprintf("creating '%s' failed: %s", outPath, err),
+		})
+		return statusFailed
+	}
+	defer out.Close()
+
+	counter := utils.NewCountingWriter(out)
+	if err := decoder.Decode(counter); err != nil {
+		out.Close()
+		_ = os.Remove(outPath)
+		utils.LogFileResult(utils.FileResult{
+			Input: filePath, DurationMs: time.Since(start).Milliseconds(),
+			Status: "error", Error: err.Error(),
+		})
+		return statusFailed
+	}
+
+	utils.LogFileResult(utils.FileResult{
+		Input: filePath, Output: outPath, Format: decoder.GetAudioExt(),
+		Bytes: counter.Count(), DurationMs: time.Since(start).Milliseconds(), Status: "ok",
+	})
+	return statusOK
+}
+
+// processNcmFile unlocks a legacy NetEase .ncm file via ncmcrypt.
+func processNcmFile(filePath string, outputDir string, opts fileOptions) fileStatus {
+	start := time.Now()
+
 	currentFile, err := ncmcrypt.NewNeteaseCloudMusic(filePath)
 	if err != nil {
-		utils.ErrorPrintfln("Reading '%s' failed: %s", filePath, err.Error())
-		return err
+		utils.LogFileResult(utils.FileResult{
+			Input: filePath, DurationMs: time.Since(start).Milliseconds(),
+			Status: "error", Error: fmt.Sprintf("reading failed: %s", err),
+		})
+		return statusFailed
+	}
+
+	outPath := currentFile.ProspectiveDumpPath(outputDir)
+	if !opts.Overwrite && utils.PathExists(outPath) {
+		utils.Infof("Skipping '%s', '%s' already exists", filePath, outPath)
+		return statusSkipped
+	}
+
+	if opts.DryRun {
+		utils.Infof("[DryRun] '%s' -> '%s'", filePath, outPath)
+		return statusOK
 	}
+
 	dump, err := currentFile.Dump(outputDir)
 	if err != nil {
-		utils.ErrorPrintfln("Processing '%s' failed: %s", filePath, err.Error())
-		return err
+		utils.LogFileResult(utils.FileResult{
+			Input: filePath, DurationMs: time.Since(start).Milliseconds(),
+			Status: "error", Error: err.Error(),
+		})
+		return statusFailed
 	}
 	if dump {
 		metadata, err := currentFile.FixMetadata(true)
 		if !metadata {
-			utils.WarningPrintfln("Fix metadata for '%s' failed: %s", filePath, err.Error())
-			return err
+			utils.LogFileResult(utils.FileResult{
+				Input: filePath, Output: currentFile.GetDumpFilePath(),
+				DurationMs: time.Since(start).Milliseconds(),
+				Status:     "error", Error: fmt.Sprintf("fixing metadata failed: %s", err),
+			})
+			return statusFailed
 		}
-		utils.DonePrintfln("'%s' -> '%s'", filePath, currentFile.GetDumpFilePath())
+
+		if opts.Verify != verifyOff {
+			level := ncmcrypt.VerifyQuick
+			if opts.Verify == verifyFull {
+				level = ncmcrypt.VerifyFull
+			}
+			if err := currentFile.Verify(level); err != nil {
+				utils.LogFileResult(utils.FileResult{
+					Input: filePath, Output: currentFile.GetDumpFilePath(),
+					DurationMs: time.Since(start).Milliseconds(),
+					Status:     "error", Error: fmt.Sprintf("verification failed: %s", err),
+				})
+				return statusFailed
+			}
+		}
+
+		utils.LogFileResult(utils.FileResult{
+			Input: filePath, Output: currentFile.GetDumpFilePath(), Format: currentFile.Format(),
+			Bytes: currentFile.BytesWritten(), DurationMs: time.Since(start).Milliseconds(), Status: "ok",
+		})
+	}
+	return statusOK
+}
+
+// processFile auto-detects filePath's format through the algo registry,
+// whose probes match on magic bytes or, for formats without a reliable
+// magic, on the file name (falling back to the .ncm extension check for
+// NetEase files, which ncmcrypt still reads straight from disk), and
+// dispatches to the matching decoder.
+func processFile(filePath string, outputDir string, opts fileOptions) fileStatus {
+	if !utils.IsRegularFile(filePath) {
+		return statusSkipped
+	}
+
+	header := make([]byte, algo.HeaderSize)
+	f, err := os.Open(filePath)
+	if err != nil {
+		return statusSkipped
+	}
+	n, _ := f.Read(header)
+	f.Close()
+	header = header[:n]
+
+	if _, newDecoder, ok := algo.Detect(filepath.Base(filePath), header); ok {
+		return processWithAlgo(filePath, outputDir, newDecoder, opts)
+	}
+
+	if filepath.Ext(filePath) != ".ncm" {
+		return statusSkipped
 	}
-	return nil
+	return processNcmFile(filePath, outputDir, opts)
 }
 
 func main() {
 	var sourceDir string
 	var outputDir string
+	var jobs int
+	var logLevel string
+	var verify string
 	showHelp := flag.BoolP("help", "h", false, "Display help message")
 	showVersion := flag.BoolP("version", "v", false, "Display version information")
 	processRecursive := flag.BoolP("recursive", "r", false, "Process all files in the directory recursively")
+	overwrite := flag.Bool("overwrite", false, "Overwrite output files that already exist (default: skip them)")
+	watch := flag.Bool("watch", false, "Keep watching --dir and process new files as they appear")
+	dryRun := flag.Bool("dry-run", false, "Report what would be produced without writing any files")
+	flag.StringVar(&verify, "verify", "", "Verify decrypted .ncm output is intact (quick, full); "+
+		"full FLAC verification only checks the first frame header's CRC-8, not STREAMINFO's MD5 against decoded PCM")
+	quiet := flag.Bool("quiet", false, "Only print warnings and errors")
+	jsonOutput := flag.Bool("json", false, "Emit one JSON record per processed file instead of colored text")
+	flag.StringVar(&logLevel, "log-level", "info", "Minimum log level to print (debug, info, warn, error)")
+	flag.IntVarP(&jobs, "jobs", "j", runtime.NumCPU(), "Number of files to process concurrently")
 	flag.StringVarP(&outputDir, "output", "o", "", "Output directory for the dump files")
 	flag.StringVarP(&sourceDir, "dir", "d", "", "Process all files in the directory")
 	flag.Parse()
 
+	utils.InitLogger(utils.ParseLogLevel(logLevel), *quiet, *jsonOutput)
+
 	if len(os.Args) == 1 {
 		flag.Usage()
 		os.Exit(0)
@@ -69,7 +266,12 @@ func main() {
 	}
 
 	if flag.Lookup("recursive").Changed && !flag.Lookup("dir").Changed {
-		utils.ErrorPrintfln("The -r option can only be used with the -d option")
+		utils.Errorf("The -r option can only be used with the -d option")
+		os.Exit(1)
+	}
+
+	if *watch && !flag.Lookup("dir").Changed {
+		utils.Errorf("The --watch option can only be used with the -d option")
 		os.Exit(1)
 	}
 
@@ -78,7 +280,7 @@ func main() {
 	if outputDirSpecified {
 		if utils.PathExists(outputDir) {
 			if !utils.IsDir(outputDir) {
-				utils.ErrorPrintfln("Output directory '%s' is not valid.", outputDir)
+				utils.Errorf("Output directory '%s' is not valid.", outputDir)
 				os.Exit(1)
 			}
 		} else {
@@ -86,32 +288,42 @@ func main() {
 		}
 	}
 
+	opts := fileOptions{Overwrite: *overwrite, DryRun: *dryRun, Verify: parseVerifyMode(verify)}
+
 	if sourceDir != "" {
 		if !utils.IsDir(sourceDir) {
-			utils.ErrorPrintfln("The source directory '%s' is not valid.", sourceDir)
+			utils.Errorf("The source directory '%s' is not valid.", sourceDir)
 			os.Exit(1)
 		}
 
+		if *watch {
+			result := watchDir(sourceDir, outputDir, jobs, opts)
+			printSummary(result)
+			return
+		}
+
+		var tasks []fileTask
+
 		if *processRecursive {
 			_ = filepath.WalkDir(sourceDir, func(p string, d os.DirEntry, err_ error) error {
+				dir := outputDir
 				if !outputDirSpecified {
-					outputDir = sourceDir
+					dir = sourceDir
 				}
 				relativePath := utils.GetRelativePath(sourceDir, p)
-				destinationPath := filepath.Join(outputDir, relativePath)
+				destinationPath := filepath.Join(dir, relativePath)
 
 				if utils.IsRegularFile(p) {
 					parentDir := filepath.Dir(destinationPath)
 					_ = os.MkdirAll(parentDir, os.ModePerm)
-					_ = processFile(p, parentDir)
+					tasks = append(tasks, fileTask{path: p, outputDir: parentDir})
 				}
 				return nil
 			})
 		} else {
-			// dump files in the folder
 			files, err := os.ReadDir(sourceDir)
 			if err != nil {
-				utils.ErrorPrintfln("Unable to read directory: '%s'", sourceDir)
+				utils.Errorf("Unable to read directory: '%s'", sourceDir)
 				os.Exit(1)
 			}
 
@@ -120,27 +332,37 @@ func main() {
 					continue
 				}
 
-				filePath := filepath.Join(sourceDir, file.Name())
+				dir := sourceDir
 				if outputDirSpecified {
-					_ = processFile(filePath, outputDir)
-				} else {
-					_ = processFile(filePath, sourceDir)
+					dir = outputDir
 				}
+				tasks = append(tasks, fileTask{path: filepath.Join(sourceDir, file.Name()), outputDir: dir})
 			}
 		}
+
+		result := runBatch(tasks, jobs, opts)
+		printSummary(result)
+		if result.Failed > 0 {
+			os.Exit(1)
+		}
 	} else {
-		// process files from args
+		var tasks []fileTask
 		for _, filePath := range flag.Args() {
-			// skip if the extension is not .ncm
-			if filePath[len(filePath)-4:] != ".ncm" {
-				continue
-			}
+			dir := sourceDir
 			if outputDirSpecified {
-				_ = processFile(filePath, outputDir)
-			} else {
-				_ = processFile(filePath, sourceDir)
+				dir = outputDir
 			}
+			tasks = append(tasks, fileTask{path: filePath, outputDir: dir})
+		}
+
+		result := runBatch(tasks, jobs, opts)
+		printSummary(result)
+		if result.Failed > 0 {
+			os.Exit(1)
 		}
 	}
+}
 
+func printSummary(result batchResult) {
+	utils.LogSummary(utils.Summary{Succeeded: result.Succeeded, Failed: result.Failed, Skipped: result.Skipped})
 }
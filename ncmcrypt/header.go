@@ -0,0 +1,211 @@
+package ncmcrypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/taurusxin/ncmdump-go/utils"
+)
+
+// sCoreKey and sModifyKey are the fixed AES keys every ncm client build
+// bakes in; they never vary per file.
+var (
+	sCoreKey   = [16]byte{0x68, 0x7A, 0x48, 0x52, 0x41, 0x6D, 0x73, 0x6F, 0x35, 0x6B, 0x49, 0x6E, 0x62, 0x61, 0x78, 0x57}
+	sModifyKey = [16]byte{0x23, 0x31, 0x34, 0x6C, 0x6A, 0x6B, 0x5F, 0x21, 0x5C, 0x5D, 0x26, 0x30, 0x55, 0x3C, 0x27, 0x28}
+	pngMagic   = [8]byte{0x89, 0x50, 0x4E, 0x47, 0x0D, 0x0A, 0x1A, 0x0A}
+)
+
+// Header carries everything NewDecryptReader can recover from a ncm
+// file's header: its tag metadata, cover art, and the detected audio
+// format of the decrypted body, so callers who only care about tags don't
+// need to decode the whole stream to get at them.
+type Header struct {
+	Metadata    *NeteaseClousMusicMetadata
+	CoverData   []byte
+	AlbumPicURL string
+	Format      NcmFormat
+}
+
+// buildKeyBox runs the RC4-style key schedule over key and returns the
+// resulting 256-byte box.
+func buildKeyBox(key []byte) [256]byte {
+	var box [256]byte
+	for i := 0; i < 256; i++ {
+		box[i] = byte(i)
+	}
+
+	var swap uint8 = 0
+	var c uint8 = 0
+	var lastByte uint8 = 0
+	var keyOffset uint8 = 0
+	keyLen := len(key)
+
+	for i := 0; i < 256; i++ {
+		swap = box[i]
+		c = (swap + lastByte + key[keyOffset]) & 0xff
+		keyOffset++
+		if int(keyOffset) >= keyLen {
+			keyOffset = 0
+		}
+		box[i] = box[c]
+		box[c] = swap
+		lastByte = c
+	}
+	return box
+}
+
+// decryptReader XORs audio bytes read from src against the keybox. The
+// cipher's keystream position depends on the absolute byte offset into
+// the decrypted body, so off must keep advancing across Read calls
+// however the caller chooses to size its reads.
+type decryptReader struct {
+	src    io.Reader
+	keyBox [256]byte
+	off    int
+}
+
+func (r *decryptReader) Read(p []byte) (int, error) {
+	n, err := r.src.Read(p)
+	for i := 0; i < n; i++ {
+		j := (r.off + i + 1) & 0xff
+		p[i] ^= r.keyBox[(r.keyBox[j]+r.keyBox[(int(r.keyBox[j])+j)&0xff])&0xff]
+	}
+	r.off += n
+	return n, err
+}
+
+func readExact(src io.Reader, size int) ([]byte, error) {
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(src, buf); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+func readUint32(src io.Reader) (int, error) {
+	buf, err := readExact(src, 4)
+	if err != nil {
+		return 0, err
+	}
+	return int(binary.LittleEndian.Uint32(buf)), nil
+}
+
+// NewDecryptReader parses a ncm file's header from src and returns an
+// io.Reader that yields the decrypted audio body, alongside the Header
+// recovered along the way. It works against any io.Reader, not just
+// *os.File, so callers can decode from memory, an HTTP body, and so on.
+func NewDecryptReader(src io.Reader) (io.Reader, *Header, error) {
+	magic, err := readExact(src, 8)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read magic failed: %w", err)
+	}
+	// check magic header 4E455443 4D414446
+	if int(binary.LittleEndian.Uint32(magic[0:4])) != 0x4E455443 ||
+		int(binary.LittleEndian.Uint32(magic[4:8])) != 0x4D414446 {
+		return nil, nil, fmt.Errorf("not a ncm file")
+	}
+
+	// actually this 2 bytes is the version, now we just skip it
+	if _, err := readExact(src, 2); err != nil {
+		return nil, nil, fmt.Errorf("seek version failed")
+	}
+
+	// the length of the RC4 key, encrypted by AES128
+	keyLen, err := readUint32(src)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read key len failed")
+	}
+	keyData, err := readExact(src, keyLen)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read key data failed")
+	}
+	for i := range keyData {
+		keyData[i] ^= 0x64
+	}
+
+	mKeyData, err := utils.AesEcbDecrypt(sCoreKey[:], keyData)
+	if err != nil {
+		return nil, nil, fmt.Errorf("decrypt key failed")
+	}
+
+	keyBox := buildKeyBox(mKeyData[17:])
+
+	metadataLen, err := readUint32(src)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read metadata len failed")
+	}
+
+	header := &Header{}
+
+	if metadataLen > 0 {
+		modifyData, err := readExact(src, metadataLen)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read metadata failed")
+		}
+		for i := range modifyData {
+			modifyData[i] ^= 0x63
+		}
+
+		// escape `163 key(Don't modify):`
+		modifyOutData, err := base64.StdEncoding.DecodeString(string(modifyData[22:]))
+		if err != nil {
+			return nil, nil, fmt.Errorf("base64 decode metadata failed: %w", err)
+		}
+
+		modifyDecryptData, err := utils.AesEcbDecrypt(sModifyKey[:], modifyOutData)
+		if err != nil {
+			return nil, nil, fmt.Errorf("decrypt metadata failed: %w", err)
+		}
+
+		// escape `music:`
+		mMetadataString := string(modifyDecryptData[6:])
+
+		header.AlbumPicURL = GetAlbumPicUrl(mMetadataString)
+		header.Metadata = NewNeteaseCloudMusicMetadata(mMetadataString)
+	}
+
+	// skip the 5 bytes gap
+	if _, err := readExact(src, 5); err != nil {
+		return nil, nil, fmt.Errorf("seek gap failed")
+	}
+
+	coverFrameLen, err := readUint32(src)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read cover frame len failed")
+	}
+	coverFrameDataLen, err := readUint32(src)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read cover frame data len failed")
+	}
+
+	if coverFrameDataLen > 0 {
+		cover, err := readExact(src, coverFrameDataLen)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read cover frame data failed")
+		}
+		header.CoverData = cover
+	}
+	if pad := coverFrameLen - coverFrameDataLen; pad > 0 {
+		if _, err := io.CopyN(io.Discard, src, int64(pad)); err != nil {
+			return nil, nil, fmt.Errorf("skip cover frame padding failed")
+		}
+	}
+
+	body := &decryptReader{src: src, keyBox: keyBox}
+
+	// peek the first bytes of the decrypted body to tell mp3 from flac,
+	// the same way Dump always has, then stitch them back onto the
+	// stream so nothing is lost for the caller.
+	peek := make([]byte, 3)
+	n, _ := io.ReadFull(body, peek)
+	if n >= 3 && bytes.Equal(peek[:3], []byte{0x49, 0x44, 0x33}) {
+		header.Format = Mp3
+	} else {
+		header.Format = Flac
+	}
+
+	return io.MultiReader(bytes.NewReader(peek[:n]), body), header, nil
+}
@@ -0,0 +1,133 @@
+package ncmcrypt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/taurusxin/ncmdump-go/utils"
+)
+
+// buildFixture assembles a minimal synthetic .ncm byte stream: magic,
+// version, an AES-ECB-encrypted-then-XOR'd RC4 key blob, no metadata, no
+// cover art, and a body XORed against the same keybox a real decrypt
+// would derive, so NewDecryptReader should recover plain unchanged.
+func buildFixture(t *testing.T, keyBoxSeed, plain []byte) []byte {
+	t.Helper()
+
+	mKeyData := append(make([]byte, 17), keyBoxSeed...)
+	encrypted, err := utils.AesEcbEncrypt(sCoreKey[:], mKeyData)
+	if err != nil {
+		t.Fatalf("AesEcbEncrypt: %v", err)
+	}
+	keyData := append([]byte{}, encrypted...)
+	for i := range keyData {
+		keyData[i] ^= 0x64
+	}
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, uint32(0x4E455443))
+	binary.Write(&buf, binary.LittleEndian, uint32(0x4D414446))
+	buf.Write([]byte{0, 0}) // version, unused
+
+	binary.Write(&buf, binary.LittleEndian, uint32(len(keyData)))
+	buf.Write(keyData)
+
+	binary.Write(&buf, binary.LittleEndian, uint32(0)) // no metadata
+
+	buf.Write(make([]byte, 5)) // gap
+
+	binary.Write(&buf, binary.LittleEndian, uint32(0)) // cover frame len
+	binary.Write(&buf, binary.LittleEndian, uint32(0)) // cover frame data len
+
+	keyBox := buildKeyBox(keyBoxSeed)
+	body := append([]byte{}, plain...)
+	keystream := make([]byte, len(body))
+	(&decryptReader{src: bytes.NewReader(make([]byte, len(body))), keyBox: keyBox}).Read(keystream)
+	for i := range body {
+		body[i] ^= keystream[i]
+	}
+	buf.Write(body)
+
+	return buf.Bytes()
+}
+
+func TestNewDecryptReaderMp3(t *testing.T) {
+	plain := append([]byte{0x49, 0x44, 0x33}, bytes.Repeat([]byte("ncm-fixture-audio"), 8)...)
+	fixture := buildFixture(t, []byte("a synthetic ncm keybox seed"), plain)
+
+	r, header, err := NewDecryptReader(bytes.NewReader(fixture))
+	if err != nil {
+		t.Fatalf("NewDecryptReader: %v", err)
+	}
+	if header.Format != Mp3 {
+		t.Errorf("Format = %q, want %q", header.Format, Mp3)
+	}
+	if header.Metadata != nil {
+		t.Errorf("Metadata = %+v, want nil for a fixture with no metadata block", header.Metadata)
+	}
+	if header.CoverData != nil {
+		t.Errorf("CoverData = %x, want nil for a fixture with no cover frame", header.CoverData)
+	}
+
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Fatalf("decrypted body mismatch:\n got: %x\nwant: %x", got, plain)
+	}
+}
+
+func TestNewDecryptReaderFlac(t *testing.T) {
+	plain := append([]byte("fLaC"), bytes.Repeat([]byte("ncm-flac-fixture"), 8)...)
+	fixture := buildFixture(t, []byte("another keybox seed"), plain)
+
+	r, header, err := NewDecryptReader(bytes.NewReader(fixture))
+	if err != nil {
+		t.Fatalf("NewDecryptReader: %v", err)
+	}
+	if header.Format != Flac {
+		t.Errorf("Format = %q, want %q", header.Format, Flac)
+	}
+
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Fatalf("decrypted body mismatch:\n got: %x\nwant: %x", got, plain)
+	}
+}
+
+// TestDecryptReaderOffsetAcrossChunks confirms the keystream offset math
+// in decryptReader.Read holds regardless of how the caller sizes its
+// reads: a single big Read must produce the same keystream bytes as many
+// small ones against a fresh reader over the same source.
+func TestDecryptReaderOffsetAcrossChunks(t *testing.T) {
+	keyBox := buildKeyBox([]byte("chunking test keybox seed"))
+	size := 97
+
+	want := make([]byte, size)
+	(&decryptReader{src: bytes.NewReader(make([]byte, size)), keyBox: keyBox}).Read(want)
+
+	for _, chunkSize := range []int{1, 3, 7, 16, 32} {
+		r := &decryptReader{src: bytes.NewReader(make([]byte, size)), keyBox: keyBox}
+		got := make([]byte, 0, size)
+		buf := make([]byte, chunkSize)
+		for {
+			n, err := r.Read(buf)
+			got = append(got, buf[:n]...)
+			if err != nil {
+				break
+			}
+			if len(got) >= size {
+				break
+			}
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("chunk size %d: keystream mismatch:\n got: %x\nwant: %x", chunkSize, got, want)
+		}
+	}
+}
@@ -1,9 +1,17 @@
 package ncmcrypt
 
 import (
+	"github.com/bogem/id3v2/v2"
+	"github.com/go-flac/flacvorbis"
 	"github.com/tidwall/gjson"
 )
 
+// NeteaseClousMusicMetadata carries the tag data embedded in a ncm file's
+// header JSON. musicId/albumId/albumPicDocId/mp3DocId are exposed as
+// strings rather than ints: NetEase emits them as JSON numbers in some
+// responses and JSON strings in others, and gjson.Result.String() is the
+// one accessor that renders either representation correctly (unlike
+// .Int(), which silently returns 0 for a string-typed value).
 type NeteaseClousMusicMetadata struct {
 	mAlbum    string
 	mArtist   string
@@ -11,6 +19,14 @@ type NeteaseClousMusicMetadata struct {
 	mName     string
 	mDuration int64
 	mBitrate  int64
+
+	mMusicId       string
+	mAlbumId       string
+	mAlbumPicDocId string
+	mMp3DocId      string
+	mTrackNumber   string
+	mYear          string
+	mGenre         string
 }
 
 func NewNeteaseCloudMusicMetadata(meta string) *NeteaseClousMusicMetadata {
@@ -44,9 +60,67 @@ func NewNeteaseCloudMusicMetadata(meta string) *NeteaseClousMusicMetadata {
 	metaData.mDuration = gjson.Get(meta, "duration").Int()
 	metaData.mFormat = gjson.Get(meta, "format").String()
 
+	metaData.mMusicId = gjson.Get(meta, "musicId").String()
+	metaData.mAlbumId = gjson.Get(meta, "albumId").String()
+	metaData.mAlbumPicDocId = gjson.Get(meta, "albumPicDocId").String()
+	metaData.mMp3DocId = gjson.Get(meta, "mp3DocId").String()
+	metaData.mTrackNumber = gjson.Get(meta, "no").String()
+	metaData.mYear = gjson.Get(meta, "year").String()
+	metaData.mGenre = gjson.Get(meta, "genre").String()
+
 	return metaData
 }
 
 func GetAlbumPicUrl(meta string) string {
 	return gjson.Get(meta, "albumPic").String()
 }
+
+// addExtendedID3Tags writes NetEase's own IDs as TXXX user-defined text
+// frames, plus track number/year/genre on their standard frames, when
+// meta has them.
+func addExtendedID3Tags(tag *id3v2.Tag, meta *NeteaseClousMusicMetadata) {
+	for description, value := range map[string]string{
+		"NETEASE_MUSIC_ID":         meta.mMusicId,
+		"NETEASE_ALBUM_ID":         meta.mAlbumId,
+		"NETEASE_ALBUM_PIC_DOC_ID": meta.mAlbumPicDocId,
+		"NETEASE_MP3_DOC_ID":       meta.mMp3DocId,
+	} {
+		if value == "" {
+			continue
+		}
+		tag.AddUserDefinedTextFrame(id3v2.UserDefinedTextFrame{
+			Encoding:    id3v2.EncodingUTF8,
+			Description: description,
+			Value:       value,
+		})
+	}
+
+	if meta.mTrackNumber != "" {
+		tag.AddTextFrame(tag.CommonID("Track number/Position in set"), id3v2.EncodingUTF8, meta.mTrackNumber)
+	}
+	if meta.mYear != "" {
+		tag.AddTextFrame(tag.CommonID("Recording time"), id3v2.EncodingUTF8, meta.mYear)
+	}
+	if meta.mGenre != "" {
+		tag.SetGenre(meta.mGenre)
+	}
+}
+
+// addExtendedVorbisComments writes the same NetEase IDs and track
+// number/year/genre as Vorbis comments, for FLAC output.
+func addExtendedVorbisComments(cmts *flacvorbis.MetaDataBlockVorbisComment, meta *NeteaseClousMusicMetadata) {
+	for field, value := range map[string]string{
+		"NETEASE_MUSIC_ID":           meta.mMusicId,
+		"NETEASE_ALBUM_ID":           meta.mAlbumId,
+		"NETEASE_ALBUM_PIC_DOC_ID":   meta.mAlbumPicDocId,
+		"NETEASE_MP3_DOC_ID":         meta.mMp3DocId,
+		flacvorbis.FIELD_TRACKNUMBER: meta.mTrackNumber,
+		flacvorbis.FIELD_DATE:        meta.mYear,
+		flacvorbis.FIELD_GENRE:       meta.mGenre,
+	} {
+		if value == "" {
+			continue
+		}
+		_ = cmts.Add(field, value)
+	}
+}
@@ -0,0 +1,56 @@
+package ncmcrypt
+
+import "testing"
+
+func TestNewNeteaseCloudMusicMetadataEmptyReturnsNil(t *testing.T) {
+	if got := NewNeteaseCloudMusicMetadata(""); got != nil {
+		t.Fatalf("expected nil for an empty meta string, got %+v", got)
+	}
+}
+
+func TestNewNeteaseCloudMusicMetadataParsesFields(t *testing.T) {
+	meta := `{
+		"musicName": "Song",
+		"album": "Album",
+		"artist": [["Artist One"], ["Artist Two"]],
+		"bitrate": 320000,
+		"duration": 240000,
+		"format": "flac",
+		"musicId": 123456,
+		"albumId": "654321",
+		"albumPicDocId": "abc123",
+		"mp3DocId": "def456",
+		"no": 3,
+		"year": "2020",
+		"genre": "Pop"
+	}`
+
+	m := NewNeteaseCloudMusicMetadata(meta)
+	if m == nil {
+		t.Fatal("expected a non-nil metadata instance")
+	}
+	if m.mName != "Song" || m.mAlbum != "Album" {
+		t.Fatalf("unexpected name/album: %+v", m)
+	}
+	if m.mArtist != "Artist One/Artist Two" {
+		t.Fatalf("expected artists to be joined with '/', got %q", m.mArtist)
+	}
+	if m.mBitrate != 320000 || m.mDuration != 240000 || m.mFormat != "flac" {
+		t.Fatalf("unexpected bitrate/duration/format: %+v", m)
+	}
+	// musicId is a JSON number here, not a string, but mMusicId must still
+	// come through as a string: gjson.String() renders either representation.
+	if m.mMusicId != "123456" || m.mAlbumId != "654321" {
+		t.Fatalf("unexpected ids: %+v", m)
+	}
+	if m.mTrackNumber != "3" || m.mYear != "2020" || m.mGenre != "Pop" {
+		t.Fatalf("unexpected track/year/genre: %+v", m)
+	}
+}
+
+func TestGetAlbumPicUrl(t *testing.T) {
+	meta := `{"albumPic": "https://example.com/pic.jpg"}`
+	if got := GetAlbumPicUrl(meta); got != "https://example.com/pic.jpg" {
+		t.Fatalf("got %q", got)
+	}
+}
@@ -0,0 +1,408 @@
+package ncmcrypt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// VerifyLevel controls how much work Verify does to confirm that dumped
+// output is intact. VerifyQuick only checks the container's magic bytes
+// and mandatory metadata. VerifyFull additionally checks frame checksums:
+// for MP3 it walks the whole frame stream and checks each frame's CRC-16
+// where present; for FLAC it checks only the first frame header's CRC-8,
+// and STREAMINFO's MD5 signature is not compared against the audio.
+type VerifyLevel int
+
+const (
+	VerifyQuick VerifyLevel = iota
+	VerifyFull
+)
+
+// Verify re-opens ncm's dumped output and confirms it's a structurally
+// intact MP3 or FLAC stream. Dump never checks its own output: a
+// truncated or corrupted .ncm source, or a keybox/mask bug, silently
+// produces broken audio, and the format is picked from the first 3 bytes
+// of the decrypted body alone. Verify is the independent check callers
+// can run afterwards.
+func (ncm *NeteaseCloudMusic) Verify(level VerifyLevel) error {
+	f, err := os.Open(ncm.mDumpFilePath)
+	if err != nil {
+		return fmt.Errorf("open dumped file failed: %w", err)
+	}
+	defer f.Close()
+
+	switch ncm.mFormat {
+	case Mp3:
+		return verifyMp3(f, level)
+	case Flac:
+		return verifyFlac(f, level)
+	default:
+		return fmt.Errorf("verify: unknown format %q", ncm.mFormat)
+	}
+}
+
+// verifyFlac checks the fLaC marker and STREAMINFO block, and on
+// VerifyFull additionally validates the first audio frame's header
+// checksum. Confirming STREAMINFO's MD5 signature against the decoded
+// PCM would need a full FLAC audio decoder, which this package doesn't
+// carry; a CRC-8-clean first frame is the best integrity signal
+// available without one.
+func verifyFlac(f *os.File, level VerifyLevel) error {
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return fmt.Errorf("read FLAC magic failed: %w", err)
+	}
+	if !bytes.Equal(magic, []byte("fLaC")) {
+		return fmt.Errorf("not a FLAC stream: missing 'fLaC' marker")
+	}
+
+	var streamInfo []byte
+	for {
+		blockHeader := make([]byte, 4)
+		if _, err := io.ReadFull(f, blockHeader); err != nil {
+			return fmt.Errorf("read metadata block header failed: %w", err)
+		}
+		last := blockHeader[0]&0x80 != 0
+		blockType := blockHeader[0] & 0x7f
+		length := int(blockHeader[1])<<16 | int(blockHeader[2])<<8 | int(blockHeader[3])
+
+		body := make([]byte, length)
+		if _, err := io.ReadFull(f, body); err != nil {
+			return fmt.Errorf("read metadata block body failed: %w", err)
+		}
+		if blockType == 0 {
+			streamInfo = body
+		}
+		if last {
+			break
+		}
+	}
+	if len(streamInfo) < 34 {
+		return fmt.Errorf("missing or truncated STREAMINFO block")
+	}
+
+	if level == VerifyQuick {
+		return nil
+	}
+
+	hdr, crc, err := readFlacFrameHeader(bufio.NewReader(f))
+	if err != nil {
+		return fmt.Errorf("first audio frame is malformed: %w", err)
+	}
+	if crc8(hdr) != crc {
+		return fmt.Errorf("first audio frame header failed CRC-8 check")
+	}
+	return nil
+}
+
+// readFlacFrameHeader consumes one FLAC frame header from r, returning its
+// raw bytes (everything the header's own CRC-8 covers) and that trailing
+// CRC-8 byte. See https://xiph.org/flac/format.html#frame_header.
+func readFlacFrameHeader(r *bufio.Reader) ([]byte, byte, error) {
+	sync := make([]byte, 2)
+	if _, err := io.ReadFull(r, sync); err != nil {
+		return nil, 0, err
+	}
+	if sync[0] != 0xFF || sync[1]&0xFC != 0xF8 {
+		return nil, 0, fmt.Errorf("invalid frame sync code")
+	}
+	hdr := append([]byte{}, sync...)
+
+	sizes := make([]byte, 2)
+	if _, err := io.ReadFull(r, sizes); err != nil {
+		return nil, 0, err
+	}
+	hdr = append(hdr, sizes...)
+	blockSizeCode := sizes[0] >> 4
+	sampleRateCode := sizes[0] & 0x0f
+
+	number, err := readUtf8CodedNumber(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	hdr = append(hdr, number...)
+
+	switch blockSizeCode {
+	case 6:
+		if b, err := readExact(r, 1); err != nil {
+			return nil, 0, err
+		} else {
+			hdr = append(hdr, b...)
+		}
+	case 7:
+		if b, err := readExact(r, 2); err != nil {
+			return nil, 0, err
+		} else {
+			hdr = append(hdr, b...)
+		}
+	}
+
+	switch sampleRateCode {
+	case 12:
+		if b, err := readExact(r, 1); err != nil {
+			return nil, 0, err
+		} else {
+			hdr = append(hdr, b...)
+		}
+	case 13, 14:
+		if b, err := readExact(r, 2); err != nil {
+			return nil, 0, err
+		} else {
+			hdr = append(hdr, b...)
+		}
+	}
+
+	crcByte, err := r.ReadByte()
+	if err != nil {
+		return nil, 0, err
+	}
+	return hdr, crcByte, nil
+}
+
+// readUtf8CodedNumber consumes FLAC's modified-UTF-8 coded frame/sample
+// number. Its decoded value isn't needed for integrity checking, just the
+// number of bytes it occupies in the header.
+func readUtf8CodedNumber(r *bufio.Reader) ([]byte, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	var extra int
+	switch {
+	case first&0x80 == 0x00:
+		extra = 0
+	case first&0xE0 == 0xC0:
+		extra = 1
+	case first&0xF0 == 0xE0:
+		extra = 2
+	case first&0xF8 == 0xF0:
+		extra = 3
+	case first&0xFC == 0xF8:
+		extra = 4
+	case first&0xFE == 0xFC:
+		extra = 5
+	case first == 0xFE:
+		extra = 6
+	default:
+		return nil, fmt.Errorf("invalid UTF-8 coded frame number")
+	}
+	buf := make([]byte, 1+extra)
+	buf[0] = first
+	if extra > 0 {
+		if _, err := io.ReadFull(r, buf[1:]); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+// crc8 implements the CRC-8 variant FLAC uses to protect its frame
+// headers: polynomial x^8+x^2+x^1+1 (0x07), MSB first, initialized to 0.
+func crc8(data []byte) byte {
+	var crc byte
+	for _, b := range data {
+		crc ^= b
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = (crc << 1) ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+var (
+	mp3BitratesV1L3 = [16]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, -1}
+	mp3BitratesV2L3 = [16]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, -1}
+	mp3SampleRates  = [3][3]int{
+		{44100, 48000, 32000}, // MPEG1
+		{22050, 24000, 16000}, // MPEG2
+		{11025, 12000, 8000},  // MPEG2.5
+	}
+)
+
+// verifyMp3 skips a leading ID3v2 tag (if present) and, on VerifyFull,
+// walks the MPEG Layer III frame stream to the end of file, checking that
+// every frame's declared length lands exactly on the next frame's sync
+// code (or the end of stream / a trailing ID3v1 tag), and that any
+// frame carrying the optional 16-bit CRC passes it.
+func verifyMp3(f *os.File, level VerifyLevel) error {
+	r := bufio.NewReader(f)
+	if err := skipID3v2Tag(r); err != nil {
+		return err
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return fmt.Errorf("read first MPEG frame failed: %w", err)
+	}
+	if header[0] != 0xFF || header[1]&0xE0 != 0xE0 {
+		return fmt.Errorf("no valid MPEG frame sync found")
+	}
+	if level == VerifyQuick {
+		return nil
+	}
+
+	for {
+		info, err := decodeMp3FrameHeader(header)
+		if err != nil {
+			return err
+		}
+
+		skip := info.length - len(header)
+		if info.hasCRC {
+			skip -= 2 + info.sideInfoLen
+			if skip < 0 {
+				return fmt.Errorf("implausible MPEG frame length %d", info.length)
+			}
+			crcField := make([]byte, 2)
+			if _, err := io.ReadFull(r, crcField); err != nil {
+				return fmt.Errorf("truncated MPEG frame: missing CRC field")
+			}
+			sideInfo := make([]byte, info.sideInfoLen)
+			if _, err := io.ReadFull(r, sideInfo); err != nil {
+				return fmt.Errorf("truncated MPEG frame: missing side info")
+			}
+			want := binary.BigEndian.Uint16(crcField)
+			got := crc16Mpeg(append(header[2:4:4], sideInfo...))
+			if got != want {
+				return fmt.Errorf("MPEG frame failed CRC-16 check: got %#04x, want %#04x", got, want)
+			}
+		}
+		if skip < 0 {
+			return fmt.Errorf("implausible MPEG frame length %d", info.length)
+		}
+		if _, err := io.CopyN(io.Discard, r, int64(skip)); err != nil {
+			return fmt.Errorf("truncated MPEG frame: expected %d more bytes", skip)
+		}
+
+		next := make([]byte, 4)
+		if _, err := io.ReadFull(r, next); err != nil {
+			return nil // ran cleanly to end of stream
+		}
+		if next[0] == 'T' && next[1] == 'A' && next[2] == 'G' {
+			return nil // trailing ID3v1 tag
+		}
+		if next[0] != 0xFF || next[1]&0xE0 != 0xE0 {
+			return fmt.Errorf("MPEG frame at declared length boundary has no sync code: stream is corrupt or truncated")
+		}
+		header = next
+	}
+}
+
+// mp3FrameInfo is what decodeMp3FrameHeader recovers from a Layer III
+// frame header: its total length, whether a CRC-16 follows it, and (when
+// it does) how many side-information bytes the CRC also covers.
+type mp3FrameInfo struct {
+	length      int
+	hasCRC      bool
+	sideInfoLen int
+}
+
+// decodeMp3FrameHeader decodes an MPEG Layer III header's bitrate and
+// sample rate fields and returns the frame's total length in bytes
+// (header included), whether a 16-bit CRC follows the header, and the
+// side-information length the CRC protects alongside the header's own
+// last two bytes. Only Layer III is supported since that's the only
+// layer ncmcrypt ever produces.
+func decodeMp3FrameHeader(header []byte) (mp3FrameInfo, error) {
+	versionBits := (header[1] >> 3) & 0x03
+	layerBits := (header[1] >> 1) & 0x03
+	hasCRC := header[1]&0x01 == 0x00
+
+	if layerBits != 0x01 {
+		return mp3FrameInfo{}, fmt.Errorf("unsupported MPEG layer (only Layer III is verified)")
+	}
+
+	var versionIdx int
+	var bitrates [16]int
+	switch versionBits {
+	case 0x03: // MPEG1
+		versionIdx, bitrates = 0, mp3BitratesV1L3
+	case 0x02: // MPEG2
+		versionIdx, bitrates = 1, mp3BitratesV2L3
+	case 0x00: // MPEG2.5
+		versionIdx, bitrates = 2, mp3BitratesV2L3
+	default:
+		return mp3FrameInfo{}, fmt.Errorf("reserved MPEG version in frame header")
+	}
+
+	bitrateIdx := (header[2] >> 4) & 0x0f
+	sampleRateIdx := (header[2] >> 2) & 0x03
+	padding := int((header[2] >> 1) & 0x01)
+	channelMode := (header[3] >> 6) & 0x03
+
+	if sampleRateIdx == 0x03 {
+		return mp3FrameInfo{}, fmt.Errorf("reserved sample rate in frame header")
+	}
+	bitrate := bitrates[bitrateIdx]
+	if bitrate <= 0 {
+		return mp3FrameInfo{}, fmt.Errorf("free or reserved bitrate in frame header")
+	}
+	sampleRate := mp3SampleRates[versionIdx][sampleRateIdx]
+
+	samplesPerFrame := 144
+	if versionIdx != 0 {
+		samplesPerFrame = 72
+	}
+	length := samplesPerFrame*bitrate*1000/sampleRate + padding
+
+	sideInfoLen := 32
+	switch {
+	case versionIdx != 0 && channelMode == 0x03: // MPEG2/2.5 mono
+		sideInfoLen = 9
+	case versionIdx != 0: // MPEG2/2.5, other modes
+		sideInfoLen = 17
+	case channelMode == 0x03: // MPEG1 mono
+		sideInfoLen = 17
+	}
+
+	return mp3FrameInfo{length: length, hasCRC: hasCRC, sideInfoLen: sideInfoLen}, nil
+}
+
+// crc16Mpeg implements the ISO/IEC 11172-3 CRC-16 used to protect a Layer
+// III frame header's tail and side information: polynomial
+// x^16+x^15+x^2+1 (0x8005), MSB first, initialized to 0xFFFF.
+func crc16Mpeg(data []byte) uint16 {
+	var crc uint16 = 0xFFFF
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = (crc << 1) ^ 0x8005
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// skipID3v2Tag consumes a leading ID3v2 tag, if present, so frame walking
+// starts at the first MPEG frame. Absence of a tag isn't an error.
+func skipID3v2Tag(r *bufio.Reader) error {
+	prefix, err := r.Peek(10)
+	if err != nil {
+		return nil
+	}
+	if !bytes.Equal(prefix[0:3], []byte("ID3")) {
+		return nil
+	}
+	size := syncSafeInt(prefix[6:10])
+	if _, err := io.CopyN(io.Discard, r, int64(10+size)); err != nil {
+		return fmt.Errorf("truncated ID3v2 tag: %w", err)
+	}
+	return nil
+}
+
+// syncSafeInt decodes a 4-byte ID3v2 "synchsafe" integer (7 significant
+// bits per byte, as used in the tag size field).
+func syncSafeInt(b []byte) int {
+	return int(b[0])<<21 | int(b[1])<<14 | int(b[2])<<7 | int(b[3])
+}
@@ -0,0 +1,129 @@
+package ncmcrypt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTempFile(t *testing.T, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "fixture")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// buildFlacFixture returns a minimal but structurally valid FLAC stream:
+// the "fLaC" marker, a STREAMINFO block, and one audio frame whose header
+// CRC-8 is correct (or deliberately wrong, if corruptCRC is set).
+func buildFlacFixture(t *testing.T, corruptCRC bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	buf.WriteString("fLaC")
+
+	blockHeader := []byte{0x80, 0x00, 0x00, 34} // last block, type 0 (STREAMINFO), length 34
+	buf.Write(blockHeader)
+	buf.Write(bytes.Repeat([]byte{0x00}, 34))
+
+	hdr := []byte{0xFF, 0xF8, 0x00, 0x00, 0x00} // sync + blocksize/samplerate codes + 1-byte frame number
+	crc := crc8(hdr)
+	if corruptCRC {
+		crc ^= 0xFF
+	}
+	buf.Write(hdr)
+	buf.WriteByte(crc)
+
+	return buf.Bytes()
+}
+
+func TestVerifyFlacAcceptsGoodFixture(t *testing.T) {
+	ncm := &NeteaseCloudMusic{mDumpFilePath: writeTempFile(t, buildFlacFixture(t, false)), mFormat: Flac}
+	if err := ncm.Verify(VerifyFull); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+}
+
+func TestVerifyFlacFlagsBadCRC(t *testing.T) {
+	ncm := &NeteaseCloudMusic{mDumpFilePath: writeTempFile(t, buildFlacFixture(t, true)), mFormat: Flac}
+	if err := ncm.Verify(VerifyFull); err == nil {
+		t.Fatal("expected a corrupted frame header CRC to be flagged")
+	}
+}
+
+func TestVerifyFlacErrorsOnTruncatedFile(t *testing.T) {
+	good := buildFlacFixture(t, false)
+	ncm := &NeteaseCloudMusic{mDumpFilePath: writeTempFile(t, good[:len(good)-3]), mFormat: Flac}
+	if err := ncm.Verify(VerifyFull); err == nil {
+		t.Fatal("expected a truncated frame header to error, not pass")
+	}
+}
+
+func TestVerifyFlacQuickSkipsFrameCheck(t *testing.T) {
+	good := buildFlacFixture(t, false)
+	ncm := &NeteaseCloudMusic{mDumpFilePath: writeTempFile(t, good[:len(good)-3]), mFormat: Flac}
+	if err := ncm.Verify(VerifyQuick); err != nil {
+		t.Fatalf("VerifyQuick shouldn't need a complete frame: %v", err)
+	}
+}
+
+// buildMp3Frame returns one CRC-protected MPEG1 Layer III frame (plus
+// trailing padding out to its declared length), using the package's own
+// decodeMp3FrameHeader/crc16Mpeg so the fixture can't drift out of sync
+// with what verifyMp3 expects.
+func buildMp3Frame(t *testing.T, corruptCRC bool) []byte {
+	t.Helper()
+	header := []byte{0xFF, 0xFA, 0x90, 0x00} // MPEG1 Layer III, CRC present, 128kbps/44100/stereo
+	info, err := decodeMp3FrameHeader(header)
+	if err != nil {
+		t.Fatalf("decodeMp3FrameHeader: %v", err)
+	}
+
+	sideInfo := bytes.Repeat([]byte{0x5A}, info.sideInfoLen)
+	crc := crc16Mpeg(append(append([]byte{}, header[2:4]...), sideInfo...))
+	if corruptCRC {
+		crc ^= 0xFFFF
+	}
+	crcField := make([]byte, 2)
+	binary.BigEndian.PutUint16(crcField, crc)
+
+	frame := append([]byte{}, header...)
+	frame = append(frame, crcField...)
+	frame = append(frame, sideInfo...)
+	remaining := info.length - len(header) - len(crcField) - info.sideInfoLen
+	frame = append(frame, bytes.Repeat([]byte{0x00}, remaining)...)
+	return frame
+}
+
+func TestVerifyMp3AcceptsGoodFixture(t *testing.T) {
+	ncm := &NeteaseCloudMusic{mDumpFilePath: writeTempFile(t, buildMp3Frame(t, false)), mFormat: Mp3}
+	if err := ncm.Verify(VerifyFull); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+}
+
+func TestVerifyMp3FlagsBadCRC(t *testing.T) {
+	ncm := &NeteaseCloudMusic{mDumpFilePath: writeTempFile(t, buildMp3Frame(t, true)), mFormat: Mp3}
+	if err := ncm.Verify(VerifyFull); err == nil {
+		t.Fatal("expected a corrupted frame CRC-16 to be flagged")
+	}
+}
+
+func TestVerifyMp3ErrorsOnTruncatedFile(t *testing.T) {
+	good := buildMp3Frame(t, false)
+	ncm := &NeteaseCloudMusic{mDumpFilePath: writeTempFile(t, good[:6]), mFormat: Mp3}
+	if err := ncm.Verify(VerifyFull); err == nil {
+		t.Fatal("expected a truncated frame to error, not pass")
+	}
+}
+
+func TestVerifyMp3QuickSkipsFrameCheck(t *testing.T) {
+	good := buildMp3Frame(t, false)
+	ncm := &NeteaseCloudMusic{mDumpFilePath: writeTempFile(t, good[:6]), mFormat: Mp3}
+	if err := ncm.Verify(VerifyQuick); err != nil {
+		t.Fatalf("VerifyQuick shouldn't need a complete frame: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/taurusxin/ncmdump-go/utils"
+)
+
+// fileTask is one file queued for processing against outputDir.
+type fileTask struct {
+	path      string
+	outputDir string
+}
+
+// fileStatus is the outcome of processing a single fileTask.
+type fileStatus string
+
+const (
+	statusOK      fileStatus = "ok"
+	statusSkipped fileStatus = "skipped"
+	statusFailed  fileStatus = "failed"
+)
+
+// batchResult tallies the outcome of a run across every submitted task.
+type batchResult struct {
+	Succeeded int
+	Failed    int
+	Skipped   int
+}
+
+// workerPool runs fileTasks through processFile using a fixed number of
+// goroutines, so a directory full of files gets unlocked in parallel
+// instead of one at a time.
+type workerPool struct {
+	opts    fileOptions
+	tasks   chan fileTask
+	results chan fileStatus
+	wg      sync.WaitGroup
+}
+
+// newWorkerPool starts jobs worker goroutines, each pulling tasks off the
+// pool's queue until it's closed.
+func newWorkerPool(jobs int, opts fileOptions) *workerPool {
+	if jobs < 1 {
+		jobs = 1
+	}
+	p := &workerPool{
+		opts:    opts,
+		tasks:   make(chan fileTask, jobs*2),
+		results: make(chan fileStatus, jobs*2),
+	}
+	for i := 0; i < jobs; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for t := range p.tasks {
+				p.results <- processFile(t.path, t.outputDir, p.opts)
+			}
+		}()
+	}
+	return p
+}
+
+// submit queues a task. It blocks only if every worker is busy and the
+// queue is full, which is fine for both batch mode and --watch.
+func (p *workerPool) submit(t fileTask) {
+	p.tasks <- t
+}
+
+// drain consumes results as they arrive, printing a running progress line
+// for each one, until closeAndWait closes the results channel. total is
+// the number of tasks known up front; pass 0 (as --watch does, since new
+// files keep arriving) to print no progress lines at all.
+func (p *workerPool) drain(total int) <-chan batchResult {
+	done := make(chan batchResult, 1)
+	go func() {
+		var res batchResult
+		count := 0
+		for status := range p.results {
+			count++
+			switch status {
+			case statusOK:
+				res.Succeeded++
+			case statusSkipped:
+				res.Skipped++
+			default:
+				res.Failed++
+			}
+			if total > 0 {
+				utils.ProgressPrintfln(count, total)
+			}
+		}
+		done <- res
+	}()
+	return done
+}
+
+// closeAndWait stops accepting new tasks and waits for the workers to
+// finish the ones already queued.
+func (p *workerPool) closeAndWait() {
+	close(p.tasks)
+	p.wg.Wait()
+	close(p.results)
+}
+
+// runBatch processes every task with jobs workers and returns once they
+// have all completed.
+func runBatch(tasks []fileTask, jobs int, opts fileOptions) batchResult {
+	pool := newWorkerPool(jobs, opts)
+	done := pool.drain(len(tasks))
+	for _, t := range tasks {
+		pool.submit(t)
+	}
+	pool.closeAndWait()
+	return <-done
+}
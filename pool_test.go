@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewWorkerPoolClampsJobsToOne(t *testing.T) {
+	p := newWorkerPool(0, fileOptions{})
+	defer p.closeAndWait()
+	if cap(p.tasks) != 2 {
+		t.Fatalf("expected jobs<1 to clamp to 1 (buffer cap 2), got cap=%d", cap(p.tasks))
+	}
+}
+
+func TestRunBatchCountsSkippedFiles(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain.txt")
+	if err := os.WriteFile(plain, []byte("not audio"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "does-not-exist")
+
+	tasks := []fileTask{
+		{path: missing, outputDir: dir},
+		{path: plain, outputDir: dir},
+	}
+
+	result := runBatch(tasks, 2, fileOptions{})
+	if result.Skipped != 2 {
+		t.Fatalf("Skipped = %d, want 2 (missing path + unrecognized format)", result.Skipped)
+	}
+	if result.Succeeded != 0 || result.Failed != 0 {
+		t.Fatalf("unexpected outcome counts: %+v", result)
+	}
+}
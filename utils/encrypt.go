@@ -4,6 +4,29 @@ import (
 	"crypto/aes"
 )
 
+// AesEcbEncrypt is the reverse of AesEcbDecrypt: it pads src with PKCS#7
+// and encrypts it block by block under key, ECB-style.
+func AesEcbEncrypt(key []byte, src []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	pad := aes.BlockSize - len(src)%aes.BlockSize
+	padded := make([]byte, len(src)+pad)
+	copy(padded, src)
+	for i := len(src); i < len(padded); i++ {
+		padded[i] = byte(pad)
+	}
+
+	dst := make([]byte, len(padded))
+	for i := 0; i < len(padded); i += aes.BlockSize {
+		block.Encrypt(dst[i:i+aes.BlockSize], padded[i:i+aes.BlockSize])
+	}
+
+	return dst, nil
+}
+
 func AesEcbDecrypt(key []byte, src []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -11,6 +12,30 @@ func ReplaceExtension(filepathStr, newExt string) string {
 	return strings.TrimSuffix(filepathStr, ext) + newExt
 }
 
+// innerCryptoExts lists the extensions streaming services disguise their
+// containers with, including the inner extension some clients double up
+// with a spoofed outer one (e.g. Kugou's "song.kgm.flac").
+var innerCryptoExts = []string{
+	".ncm", ".qmc0", ".qmcflac", ".qmc3", ".qmc2",
+	".mflac", ".mflac0", ".mgg", ".mgg1",
+	".kgm", ".kgma", ".vpr", ".kwm", ".xm",
+}
+
+// DecodedFilePath builds the output path for a decoded file: it replaces
+// the outer extension with audioExt, and additionally strips a disguised
+// inner crypto extension if one is present (e.g. "song.kgm.flac" -> "song.flac").
+func DecodedFilePath(srcPath, audioExt string) string {
+	base := strings.TrimSuffix(srcPath, filepath.Ext(srcPath))
+	inner := filepath.Ext(base)
+	for _, ext := range innerCryptoExts {
+		if strings.EqualFold(inner, ext) {
+			base = strings.TrimSuffix(base, inner)
+			break
+		}
+	}
+	return base + audioExt
+}
+
 func PathExists(path string) bool {
 	_, err := os.Stat(path)
 	if err == nil {
@@ -46,3 +71,25 @@ func IsRegularFile(path string) bool {
 	}
 	return s.Mode().IsRegular()
 }
+
+// CountingWriter wraps an io.Writer and tracks how many bytes have passed
+// through it, so callers can report output size without a second pass
+// over the file.
+type CountingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func NewCountingWriter(w io.Writer) *CountingWriter {
+	return &CountingWriter{w: w}
+}
+
+func (c *CountingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *CountingWriter) Count() int64 {
+	return c.n
+}
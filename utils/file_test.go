@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReplaceExtension(t *testing.T) {
+	if got := ReplaceExtension("song.ncm", ".flac"); got != "song.flac" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestDecodedFilePath(t *testing.T) {
+	cases := []struct{ src, ext, want string }{
+		{"song.ncm", ".flac", "song.flac"},
+		{"song.kgm.flac", ".flac", "song.flac"},
+		{"song.mflac", ".flac", "song.flac"},
+		{"plain.mp3", ".mp3", "plain.mp3"},
+	}
+	for _, c := range cases {
+		if got := DecodedFilePath(c.src, c.ext); got != c.want {
+			t.Errorf("DecodedFilePath(%q, %q) = %q, want %q", c.src, c.ext, got, c.want)
+		}
+	}
+}
+
+func TestPathExistsAndIsDir(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "f.txt")
+	if err := os.WriteFile(file, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if !PathExists(dir) || !PathExists(file) {
+		t.Fatal("expected both the dir and file to exist")
+	}
+	if PathExists(filepath.Join(dir, "missing")) {
+		t.Fatal("expected a missing path to report false")
+	}
+	if !IsDir(dir) || IsDir(file) {
+		t.Fatal("IsDir should distinguish a directory from a file")
+	}
+}
+
+func TestIsRegularFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "f.txt")
+	if err := os.WriteFile(file, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if !IsRegularFile(file) {
+		t.Fatal("expected a plain file to be regular")
+	}
+	if IsRegularFile(dir) {
+		t.Fatal("expected a directory to not be regular")
+	}
+	if IsRegularFile(filepath.Join(dir, "missing")) {
+		t.Fatal("expected a missing path to not be regular")
+	}
+}
+
+func TestGetRelativePath(t *testing.T) {
+	want := filepath.Join("c", "d.txt")
+	if got := GetRelativePath("/a/b", "/a/b/c/d.txt"); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestCountingWriter(t *testing.T) {
+	var buf bytes.Buffer
+	cw := NewCountingWriter(&buf)
+	n, err := cw.Write([]byte("hello"))
+	if err != nil || n != 5 {
+		t.Fatalf("Write: n=%d err=%v", n, err)
+	}
+	if cw.Count() != 5 {
+		t.Fatalf("Count() = %d, want 5", cw.Count())
+	}
+}
@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/TwiN/go-color"
+)
+
+// logLevel mirrors slog's levels under the repo's existing naming scheme
+// so --log-level can take the familiar debug/info/warn/error strings.
+var logLevel = slog.LevelInfo
+
+var (
+	quietMode bool
+	jsonMode  bool
+)
+
+// ParseLogLevel maps a --log-level string to a slog.Level, defaulting to
+// info for an empty or unrecognized value.
+func ParseLogLevel(s string) slog.Level {
+	switch strings.ToLower(s) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// InitLogger configures the package's logging mode for the rest of the
+// process. level controls which of Debugf/Infof/Warnf/Errorf actually
+// print; quiet suppresses everything below warn; json switches per-file
+// results (see LogFileResult) to one JSON record per line instead of the
+// colored TTY format.
+func InitLogger(level slog.Level, quiet bool, json bool) {
+	logLevel = level
+	quietMode = quiet
+	jsonMode = json
+}
+
+// printMu serializes writes to stdout so concurrent workers in a batch run
+// don't interleave their status lines.
+var printMu sync.Mutex
+
+// colorEnabled is decided once at startup: the colored TTY format is the
+// default only when stdout is actually a terminal, so output piped to a
+// file or into a log aggregator doesn't carry raw ANSI escapes.
+var colorEnabled = isTerminal(os.Stdout)
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+func logEnabled(level slog.Level) bool {
+	if quietMode && level < slog.LevelWarn {
+		return false
+	}
+	return level >= logLevel
+}
+
+func printLine(prefix string, colorFn func(any) string, format string, a ...interface{}) {
+	printMu.Lock()
+	defer printMu.Unlock()
+	label := prefix
+	if colorEnabled {
+		label = color.InBold(colorFn(prefix))
+	}
+	fmt.Printf(label+format+"\n", a...)
+}
+
+func Debugf(format string, a ...interface{}) {
+	if !logEnabled(slog.LevelDebug) {
+		return
+	}
+	printLine("[Debug] ", color.InGray, format, a...)
+}
+
+func Infof(format string, a ...interface{}) {
+	if !logEnabled(slog.LevelInfo) {
+		return
+	}
+	printLine("[Info] ", color.InBlue, format, a...)
+}
+
+func Warnf(format string, a ...interface{}) {
+	if !logEnabled(slog.LevelWarn) {
+		return
+	}
+	printLine("[Warning] ", color.InYellow, format, a...)
+}
+
+func Errorf(format string, a ...interface{}) {
+	if !logEnabled(slog.LevelError) {
+		return
+	}
+	printLine("[Error] ", color.InRed, format, a...)
+}
+
+func Donef(format string, a ...interface{}) {
+	if !logEnabled(slog.LevelInfo) {
+		return
+	}
+	printLine("[Done] ", color.InGreen, format, a...)
+}
+
+// ProgressPrintfln prints a "[n/total]" progress line. It has no JSON
+// form (LogFileResult already reports each file's outcome as a record),
+// so it's suppressed entirely in --json mode rather than interleaving
+// plain text into a JSONL stream.
+func ProgressPrintfln(current, total int) {
+	if jsonMode || !logEnabled(slog.LevelInfo) {
+		return
+	}
+	printLine(fmt.Sprintf("[%d/%d] ", current, total), color.InCyan, "done")
+}
+
+// FileResult is the outcome of processing a single file. In --json mode
+// it is emitted verbatim as one JSON record per file; otherwise it's
+// rendered as a regular colored Done/Error line.
+type FileResult struct {
+	Input      string `json:"input"`
+	Output     string `json:"output,omitempty"`
+	Format     string `json:"format,omitempty"`
+	Bytes      int64  `json:"bytes,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+}
+
+// LogFileResult reports a single file's processing outcome, either as a
+// JSON record (for --json / CI / log aggregators) or as the usual colored
+// status line.
+func LogFileResult(r FileResult) {
+	if jsonMode {
+		printMu.Lock()
+		defer printMu.Unlock()
+		line, err := json.Marshal(r)
+		if err != nil {
+			return
+		}
+		fmt.Println(string(line))
+		return
+	}
+
+	if r.Status == "ok" {
+		Donef("'%s' -> '%s'", r.Input, r.Output)
+		return
+	}
+	Errorf("Processing '%s' failed: %s", r.Input, r.Error)
+}
+
+// Summary tallies a batch run's outcome across every submitted file. In
+// --json mode it is emitted as a final JSON record, same as every
+// per-file FileResult; otherwise as the usual colored status line.
+type Summary struct {
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Skipped   int `json:"skipped"`
+}
+
+// LogSummary reports a batch run's totals, either as a JSON record or the
+// usual colored status line.
+func LogSummary(s Summary) {
+	if jsonMode {
+		printMu.Lock()
+		defer printMu.Unlock()
+		line, err := json.Marshal(s)
+		if err != nil {
+			return
+		}
+		fmt.Println(string(line))
+		return
+	}
+	Infof("Succeeded: %d, Failed: %d, Skipped: %d", s.Succeeded, s.Failed, s.Skipped)
+}
@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+)
+
+// withLogger runs fn under the given logger configuration and restores the
+// previous one afterwards, so tests can't leak jsonMode/quietMode/logLevel
+// into each other.
+func withLogger(t *testing.T, level slog.Level, quiet, jsonOut bool, fn func()) {
+	t.Helper()
+	prevLevel, prevQuiet, prevJSON := logLevel, quietMode, jsonMode
+	InitLogger(level, quiet, jsonOut)
+	t.Cleanup(func() { InitLogger(prevLevel, prevQuiet, prevJSON) })
+	fn()
+}
+
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	old := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+	fn()
+	w.Close()
+	os.Stdout = old
+
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestParseLogLevel(t *testing.T) {
+	cases := map[string]slog.Level{
+		"debug":   slog.LevelDebug,
+		"DEBUG":   slog.LevelDebug,
+		"warn":    slog.LevelWarn,
+		"warning": slog.LevelWarn,
+		"error":   slog.LevelError,
+		"info":    slog.LevelInfo,
+		"":        slog.LevelInfo,
+		"bogus":   slog.LevelInfo,
+	}
+	for s, want := range cases {
+		if got := ParseLogLevel(s); got != want {
+			t.Errorf("ParseLogLevel(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestLogFileResultJSONMode(t *testing.T) {
+	withLogger(t, slog.LevelInfo, false, true, func() {
+		out := captureStdout(t, func() {
+			LogFileResult(FileResult{Input: "a.ncm", Output: "a.flac", Status: "ok"})
+		})
+		var r FileResult
+		if err := json.Unmarshal([]byte(strings.TrimSpace(out)), &r); err != nil {
+			t.Fatalf("output isn't valid JSON: %v\noutput: %s", err, out)
+		}
+		if r.Input != "a.ncm" || r.Status != "ok" {
+			t.Fatalf("unexpected record: %+v", r)
+		}
+	})
+}
+
+func TestLogFileResultTextMode(t *testing.T) {
+	withLogger(t, slog.LevelInfo, false, false, func() {
+		out := captureStdout(t, func() {
+			LogFileResult(FileResult{Input: "a.ncm", Error: "boom", Status: "error"})
+		})
+		if !strings.Contains(out, "a.ncm") || !strings.Contains(out, "boom") {
+			t.Fatalf("expected text output to mention input and error, got: %s", out)
+		}
+	})
+}
+
+func TestLogSummaryJSONMode(t *testing.T) {
+	withLogger(t, slog.LevelInfo, false, true, func() {
+		out := captureStdout(t, func() {
+			LogSummary(Summary{Succeeded: 1, Failed: 2, Skipped: 3})
+		})
+		var s Summary
+		if err := json.Unmarshal([]byte(strings.TrimSpace(out)), &s); err != nil {
+			t.Fatalf("output isn't valid JSON: %v\noutput: %s", err, out)
+		}
+		if s != (Summary{Succeeded: 1, Failed: 2, Skipped: 3}) {
+			t.Fatalf("unexpected summary: %+v", s)
+		}
+	})
+}
+
+func TestQuietModeSuppressesInfoAndProgress(t *testing.T) {
+	withLogger(t, slog.LevelInfo, true, false, func() {
+		out := captureStdout(t, func() {
+			Infof("should be suppressed")
+			ProgressPrintfln(1, 2)
+		})
+		if out != "" {
+			t.Fatalf("expected no output in quiet mode for info-level messages, got: %q", out)
+		}
+	})
+}
+
+func TestIsTerminalFalseForRegularFile(t *testing.T) {
+	f, err := os.CreateTemp("", "log-test-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if isTerminal(f) {
+		t.Fatal("expected a regular file to not be reported as a terminal")
+	}
+}
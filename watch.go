@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/taurusxin/ncmdump-go/utils"
+)
+
+// watchDir processes files already in sourceDir, then keeps watching it
+// and feeds every new file dropped in to the pool until interrupted
+// (Ctrl+C).
+func watchDir(sourceDir, outputDir string, jobs int, opts fileOptions) batchResult {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		utils.Errorf("Unable to start watcher: %s", err.Error())
+		return batchResult{}
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(sourceDir); err != nil {
+		utils.Errorf("Unable to watch '%s': %s", sourceDir, err.Error())
+		return batchResult{}
+	}
+
+	pool := newWorkerPool(jobs, opts)
+	done := pool.drain(0)
+
+	entries, err := os.ReadDir(sourceDir)
+	if err == nil {
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				pool.submit(fileTask{path: filepath.Join(sourceDir, entry.Name()), outputDir: outputDir})
+			}
+		}
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+
+	utils.Infof("Watching '%s' for new files, press Ctrl+C to stop", sourceDir)
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				pool.closeAndWait()
+				return <-done
+			}
+			if event.Op&fsnotify.Create == fsnotify.Create && utils.IsRegularFile(event.Name) {
+				pool.submit(fileTask{path: event.Name, outputDir: outputDir})
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				pool.closeAndWait()
+				return <-done
+			}
+			utils.Warnf("Watcher error: %s", err.Error())
+		case <-interrupt:
+			pool.closeAndWait()
+			return <-done
+		}
+	}
+}
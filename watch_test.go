@@ -0,0 +1,17 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// watchDir's happy path blocks on fsnotify events and OS signals with no
+// seam to trigger or stop it from a test, so the one thing that can be
+// exercised safely and deterministically is its up-front failure path.
+func TestWatchDirReturnsEmptyResultForUnwatchableDir(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "does-not-exist")
+	result := watchDir(missing, "", 1, fileOptions{})
+	if result != (batchResult{}) {
+		t.Fatalf("expected a zero-value batchResult when the directory can't be watched, got %+v", result)
+	}
+}